@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"fmt"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	cmdkit "gx/ipfs/QmceUdzxkimdYsgtX733uNgzf1DLHyBKN6ehGSp85ayppM/go-ipfs-cmdkit"
+)
+
+// PinCmd is the parent for pin management: adding, removing and listing
+// the objects pinned to local storage.
+var PinCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin (and unpin) objects to local storage.",
+		ShortDescription: `
+'ipfs pin' is a plumbing command used to increase the reference count of
+objects (pin) or decrease it (unpin), and to list what's pinned.
+`,
+	},
+	Subcommands: map[string]*oldcmds.Command{
+		"add": addPinCmd,
+		"rm":  rmPinCmd,
+		"ls":  listPinCmd,
+	},
+}
+
+// PinOutput is the result of a "pin add" or "pin rm" call.
+type PinOutput struct {
+	Pins []string
+}
+
+var addPinCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin objects to local storage.",
+		ShortDescription: `
+Retrieves the object named by <ipfs-path> and stores it locally
+on disk.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "Path to object(s) to be pinned.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
+		cmdkit.StringOption("group", "Tag the pin with a retention group, so 'ipfs repo forget --group=NAME' can apply a policy to it later."),
+	},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		recursive, _, err := req.Option("recursive").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		group, _, err := req.Option("group").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := make([]string, len(added))
+		for i, c := range added {
+			out[i] = c.String()
+			if group != "" {
+				if err := RecordPinGroup(n.Repo.Datastore(), c, group, nil); err != nil {
+					res.SetError(fmt.Errorf("pinned %s but failed to record its --group: %s", c, err), cmdkit.ErrNormal)
+					return
+				}
+			}
+		}
+
+		res.SetOutput(&PinOutput{Pins: out})
+	},
+	Type: PinOutput{},
+}
+
+var rmPinCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove pinned objects from local storage.",
+		ShortDescription: `
+Removes the pin from the given object, allowing it to be garbage
+collected if needed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, true, "CID(s) of the object(s) to be unpinned; paths are not resolved.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("recursive", "r", "Recursively unpin the object linked to by the specified object(s).").WithDefault(true),
+	},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		recursive, _, err := req.Option("recursive").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		removed := make([]string, 0, len(req.Arguments()))
+		for _, arg := range req.Arguments() {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid pin CID %q: %s", arg, err), cmdkit.ErrClient)
+				return
+			}
+
+			if err := n.Pinning.Unpin(req.Context(), c, recursive); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			removed = append(removed, c.String())
+		}
+
+		if err := n.Pinning.Flush(); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PinOutput{Pins: removed})
+	},
+	Type: PinOutput{},
+}
+
+// PinLsOutput is the result of a "pin ls" call: a map from CID string to
+// the reason it's pinned.
+type PinLsOutput struct {
+	Keys map[string]string
+}
+
+var listPinCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List objects pinned to local storage.",
+		ShortDescription: `
+Returns a list of objects that are pinned locally.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "t", "The type of pinned keys to list (direct, recursive, all).").WithDefault("all"),
+	},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		typ, _, err := req.Option("type").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		switch typ {
+		case "direct", "recursive", "all":
+		default:
+			res.SetError(fmt.Errorf("invalid type %q: must be direct, recursive or all", typ), cmdkit.ErrClient)
+			return
+		}
+
+		keys := make(map[string]string)
+		if typ == "recursive" || typ == "all" {
+			for _, c := range n.Pinning.RecursiveKeys() {
+				keys[c.String()] = "recursive"
+			}
+		}
+		if typ == "direct" || typ == "all" {
+			for _, c := range n.Pinning.DirectKeys() {
+				keys[c.String()] = "direct"
+			}
+		}
+
+		res.SetOutput(&PinLsOutput{Keys: keys})
+	},
+	Type: PinLsOutput{},
+}
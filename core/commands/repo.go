@@ -2,12 +2,17 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
@@ -17,6 +22,7 @@ import (
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	dshelp "gx/ipfs/QmSz8kAe2JCKp2dWSG8gHSWnwSmne8aEppmo22rKauNw8L/go-ipfs-ds-help"
 	cmds "gx/ipfs/QmTjNRVt2fvaRFu93keEC7z5M1GS1iH6qZ9227htQioTUY/go-ipfs-cmds"
 	b58 "gx/ipfs/QmWFAMPqsEyUX7gDUsRVmMWz59FxSpJ1b2v6bJ1yYzo7jY/go-base58-fast/base58"
 	ds "gx/ipfs/QmXRKBQA4wXP7xWbFiZsR1GP4HV6wMDQ1aWFxZZ4uBcPX9/go-datastore"
@@ -44,6 +50,11 @@ var RepoCmd = &cmds.Command{
 		"version": lgc.NewCommand(repoVersionCmd),
 		"verify":  lgc.NewCommand(repoVerifyCmd),
 		"rm-root": repoRmRootCmd,
+		"prune":   repoPruneCmd,
+		"check":   repoCheckCmd,
+		"migrate": lgc.NewCommand(repoMigrateCmd),
+		"forget":  repoForgetCmd,
+		"cat":     repoCatCmd,
 	},
 }
 
@@ -364,14 +375,65 @@ This command can only run when the ipfs daemon is not running.
 }
 
 type VerifyProgress struct {
-	Msg      string
-	Progress int
+	Msg         string
+	Progress    int
+	Done        int      `json:",omitempty"`
+	Total       int      `json:",omitempty"`
+	BytesRead   uint64   `json:",omitempty"`
+	CorruptCIDs []string `json:",omitempty"`
+	ETASeconds  int      `json:",omitempty"`
+}
+
+// appendVerifyCheckpoint records that cidStrs have been verified, so a
+// Ctrl-C'd "repo verify --checkpoint" run can be resumed with "--resume"
+// against the same file. Verification is parallel across --jobs workers,
+// so there is no single monotonic "furthest CID" to persist (AllKeysChan
+// gives no ordering guarantee, and whichever worker finishes last is
+// arbitrary) -- the file instead accumulates the exact set of CIDs
+// completed so far, one per line, so resume can skip precisely those and
+// nothing else.
+func appendVerifyCheckpoint(path string, cidStrs []string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, s := range cidStrs {
+		if _, err := fmt.Fprintln(f, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readVerifyCheckpoint(path string) (map[string]bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			done[line] = true
+		}
+	}
+	return done, nil
 }
 
 var repoVerifyCmd = &oldcmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Verify all blocks in repo are not corrupted.",
 	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("jobs", "Number of parallel verification workers (default NumCPU)."),
+		cmdkit.StringOption("checkpoint", "Periodically append every verified CID to this file (one per line, not a single last position)."),
+		cmdkit.StringOption("resume", "Resume a run, skipping every CID listed in this checkpoint file."),
+		cmdkit.BoolOption("fail-fast", "Stop at the first corrupt block found."),
+		cmdkit.BoolOption("quarantine", "Move corrupt blocks into a badblocks/ namespace instead of leaving them in place."),
+	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
 		nd, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -379,49 +441,174 @@ var repoVerifyCmd = &oldcmds.Command{
 			return
 		}
 
+		jobs, jobsSet, _ := req.Option("jobs").Int()
+		if !jobsSet || jobs < 1 {
+			jobs = runtime.NumCPU()
+		}
+		checkpointFile, _, _ := req.Option("checkpoint").String()
+		resumeFile, _, _ := req.Option("resume").String()
+		failFast, _, _ := req.Option("fail-fast").Bool()
+		quarantine, _, _ := req.Option("quarantine").Bool()
+
+		var alreadyDone map[string]bool
+		if resumeFile != "" {
+			alreadyDone, err = readVerifyCheckpoint(resumeFile)
+			if err != nil {
+				res.SetError(fmt.Errorf("unable to read --resume checkpoint: %s", err), cmdkit.ErrNormal)
+				return
+			}
+		}
+
 		out := make(chan interface{})
 		res.SetOutput((<-chan interface{})(out))
 		defer close(out)
 
-		bs := bstore.NewBlockstore(nd.Repo.Datastore())
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+
+		dstore := nd.Repo.Datastore()
+		bs := bstore.NewBlockstore(dstore)
 		bs.HashOnRead(true)
 
-		keys, err := bs.AllKeysChan(req.Context())
+		stat, err := corerepo.RepoStat(nd, ctx)
 		if err != nil {
 			log.Error(err)
 			return
 		}
 
-		var fails int
-		var i int
-		for k := range keys {
-			_, err := bs.Get(k)
-			if err != nil {
+		keys, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		type result struct {
+			k    *cid.Cid
+			size int
+			err  error
+		}
+
+		jobCh := make(chan *cid.Cid, jobs*4)
+		resultCh := make(chan result, jobs*4)
+
+		var wg sync.WaitGroup
+		wg.Add(jobs)
+		for w := 0; w < jobs; w++ {
+			go func() {
+				defer wg.Done()
+				for k := range jobCh {
+					blk, err := bs.Get(k)
+					size := 0
+					if blk != nil {
+						size = len(blk.RawData())
+					}
+					select {
+					case resultCh <- result{k: k, size: size, err: err}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		go func() {
+			defer close(jobCh)
+			for k := range keys {
+				if alreadyDone[k.String()] {
+					continue
+				}
 				select {
-				case out <- &VerifyProgress{
-					Msg: fmt.Sprintf("block %s was corrupt (%s)", k, err),
-				}:
-				case <-req.Context().Done():
+				case jobCh <- k:
+				case <-ctx.Done():
 					return
 				}
+			}
+		}()
+
+		go func() {
+			wg.Wait()
+			close(resultCh)
+		}()
+
+		var fails int
+		var done int
+		var bytesRead uint64
+		var pendingCheckpoints []string
+		start := time.Now()
+		lastCheckpoint := time.Now()
+
+		for r := range resultCh {
+			done++
+			if checkpointFile != "" {
+				pendingCheckpoints = append(pendingCheckpoints, r.k.String())
+			}
+			if r.err != nil {
 				fails++
+				msg := fmt.Sprintf("block %s was corrupt (%s)", r.k, r.err)
+				if quarantine {
+					// bs.Get would re-verify the hash and fail the same way
+					// the check above just did, so read the raw bytes
+					// straight off the datastore -- under the "/blocks"
+					// namespace the blockstore actually stores them in,
+					// not the bare key (which never exists).
+					blockKey := ds.NewKey("/blocks").Child(dshelp.CidToDsKey(r.k))
+					if raw, getErr := dstore.Get(blockKey); getErr == nil {
+						if putErr := dstore.Put(ds.NewKey("/badblocks/"+r.k.String()), raw); putErr == nil {
+							bs.DeleteBlock(r.k)
+							msg += ", quarantined"
+						}
+					}
+				}
+				select {
+				case out <- &VerifyProgress{Msg: msg, CorruptCIDs: []string{r.k.String()}}:
+				case <-ctx.Done():
+					return
+				}
+				if failFast {
+					cancel()
+					break
+				}
+			} else {
+				bytesRead += uint64(r.size)
 			}
-			i++
+
+			elapsed := time.Since(start).Seconds()
+			var eta int
+			if done > 0 && elapsed > 0 && stat.NumObjects > done {
+				rate := float64(done) / elapsed
+				eta = int(float64(stat.NumObjects-done) / rate)
+			}
+
 			select {
-			case out <- &VerifyProgress{Progress: i}:
-			case <-req.Context().Done():
+			case out <- &VerifyProgress{Progress: done, Done: done, Total: stat.NumObjects, BytesRead: bytesRead, ETASeconds: eta}:
+			case <-ctx.Done():
 				return
 			}
+
+			if checkpointFile != "" && time.Since(lastCheckpoint) > time.Second {
+				if err := appendVerifyCheckpoint(checkpointFile, pendingCheckpoints); err != nil {
+					log.Error(err)
+				} else {
+					pendingCheckpoints = nil
+				}
+				lastCheckpoint = time.Now()
+			}
+		}
+
+		if checkpointFile != "" && len(pendingCheckpoints) > 0 {
+			if err := appendVerifyCheckpoint(checkpointFile, pendingCheckpoints); err != nil {
+				log.Error(err)
+			}
 		}
 
 		if fails == 0 {
 			select {
 			case out <- &VerifyProgress{Msg: "verify complete, all blocks validated."}:
-			case <-req.Context().Done():
+			case <-ctx.Done():
 				return
 			}
 		} else {
-			res.SetError(fmt.Errorf("verify complete, some blocks were corrupt"), cmdkit.ErrNormal)
+			res.SetError(fmt.Errorf("verify complete, %d blocks were corrupt", fails), cmdkit.ErrNormal)
 		}
 	},
 	Type: &VerifyProgress{},
@@ -451,7 +638,7 @@ var repoVerifyCmd = &oldcmds.Command{
 				return buf, nil
 			}
 
-			fmt.Fprintf(buf, "%d blocks processed.\r", obj.Progress)
+			fmt.Fprintf(buf, "%d/%d blocks processed (eta %ds).\r", obj.Done, obj.Total, obj.ETASeconds)
 			return buf, nil
 		},
 	},
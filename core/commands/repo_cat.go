@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+
+	cmds "gx/ipfs/QmTjNRVt2fvaRFu93keEC7z5M1GS1iH6qZ9227htQioTUY/go-ipfs-cmds"
+	ds "gx/ipfs/QmXRKBQA4wXP7xWbFiZsR1GP4HV6wMDQ1aWFxZZ4uBcPX9/go-datastore"
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	cmdkit "gx/ipfs/QmceUdzxkimdYsgtX733uNgzf1DLHyBKN6ehGSp85ayppM/go-ipfs-cmdkit"
+)
+
+// catResolver resolves a "repo cat" subject name (and an optional
+// argument, e.g. the pin CID for "pin <cid>") to the internal object it
+// names. New object kinds are added here without touching the command
+// wiring below.
+type catResolver func(n *core.IpfsNode, arg string) (interface{}, error)
+
+var repoCatRegistry = map[string]catResolver{
+	"config":    catConfig,
+	"filesroot": catFilesRoot,
+	"pin":       catPin,
+	"key":       catKey,
+	"dsentry":   catDsEntry,
+}
+
+func catConfig(n *core.IpfsNode, arg string) (interface{}, error) {
+	return n.Repo.Config()
+}
+
+func catFilesRoot(n *core.IpfsNode, arg string) (interface{}, error) {
+	val, err := n.Repo.Datastore().Get(core.FilesRootKey())
+	if err != nil {
+		return nil, err
+	}
+	c, err := cid.Cast(val.([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return c.String(), nil
+}
+
+func catPin(n *core.IpfsNode, arg string) (interface{}, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("pin requires a CID argument")
+	}
+	c, err := cid.Decode(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pin CID %q: %s", arg, err)
+	}
+
+	mode, pinned, err := n.Pinning.IsPinned(c)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := map[string]interface{}{
+		"cid":    c.String(),
+		"pinned": pinned,
+		"mode":   mode,
+	}
+
+	raw, err := n.Repo.Datastore().Get(pinMetaKey(c))
+	if err == nil {
+		entry["metadataRaw"] = raw
+	}
+
+	return entry, nil
+}
+
+// catKey only supports looking up the node's own identity: this repo has
+// no keystore accessor for arbitrary peer IDs, so rather than guess at an
+// interface that may not match whatever the real Repo type exposes, we
+// say so plainly instead of silently returning the wrong thing.
+func catKey(n *core.IpfsNode, arg string) (interface{}, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("key requires a peer ID argument")
+	}
+
+	if arg == n.Identity.Pretty() {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		return cfg.Identity, nil
+	}
+
+	return nil, fmt.Errorf("repo cat key does not support arbitrary keystore lookups yet; only the node's own identity (%s) can be read", n.Identity.Pretty())
+}
+
+func catDsEntry(n *core.IpfsNode, arg string) (interface{}, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("dsentry requires a base32-encoded datastore key")
+	}
+	raw, err := base32.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 key %q: %s", arg, err)
+	}
+	return n.Repo.Datastore().Get(ds.NewKey(string(raw)))
+}
+
+var repoCatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pretty-print internal repo objects by name.",
+		ShortDescription: `
+'ipfs repo cat' gives operators a supported way to inspect repo
+internals during incident response, instead of opening the datastore
+directly (which is both unsafe, since it locks, and undocumented).
+
+Subjects:
+
+  ipfs repo cat config
+  ipfs repo cat filesroot
+  ipfs repo cat pin <cid>
+  ipfs repo cat key <peerid>   (only this node's own identity is supported)
+  ipfs repo cat dsentry <base32-key>
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("subject", true, false, "Object kind to cat (config, filesroot, pin, key, dsentry)."),
+		cmdkit.StringArg("arg", false, false, "Argument for the subject, if it takes one."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("raw", "Output the raw bytes instead of JSON."),
+		cmdkit.BoolOption("hex", "Hex-dump the raw bytes instead of JSON."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		subject := req.Arguments[0]
+		var arg string
+		if len(req.Arguments) > 1 {
+			arg = req.Arguments[1]
+		}
+
+		resolve, ok := repoCatRegistry[subject]
+		if !ok {
+			res.SetError(fmt.Errorf("unknown repo cat subject %q", subject), cmdkit.ErrClient)
+			return
+		}
+
+		obj, err := resolve(n, arg)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, obj)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			raw, _ := req.Options["raw"].(bool)
+			hexDump, _ := req.Options["hex"].(bool)
+
+			if b, ok := v.([]byte); ok {
+				switch {
+				case hexDump:
+					_, err := fmt.Fprintf(w, "%x\n", b)
+					return err
+				case raw:
+					_, err := w.Write(b)
+					return err
+				}
+			}
+
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		}),
+	},
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"testing"
+
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+)
+
+// sampleCids are real CIDv0s (well-known IPFS test fixtures), used so the
+// test doesn't need to construct a multihash by hand.
+var sampleCids = []string{
+	"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+	"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
+	"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB",
+	"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+	"QmZULkCELmmk5XNfCgTnCyFdEsig54nnNnA1mHirXJ2CpQ",
+}
+
+func TestShardOfMatchesExactlyOneShard(t *testing.T) {
+	const m = 4
+	for _, s := range sampleCids {
+		c, err := cid.Decode(s)
+		if err != nil {
+			t.Fatalf("cid.Decode(%q): %s", s, err)
+		}
+		hits := 0
+		for n := 0; n < m; n++ {
+			if shardOf(c, n, m) {
+				hits++
+			}
+		}
+		if hits != 1 {
+			t.Errorf("cid %s matched %d of %d shards, want exactly 1", s, hits, m)
+		}
+	}
+}
+
+func TestShardOfIsDeterministic(t *testing.T) {
+	c, err := cid.Decode(sampleCids[0])
+	if err != nil {
+		t.Fatalf("cid.Decode: %s", err)
+	}
+	first := shardOf(c, 2, 4)
+	for i := 0; i < 5; i++ {
+		if shardOf(c, 2, 4) != first {
+			t.Fatalf("shardOf is not deterministic across repeated calls")
+		}
+	}
+}
+
+func TestShardOfSingleShardMatchesEverything(t *testing.T) {
+	c, err := cid.Decode(sampleCids[0])
+	if err != nil {
+		t.Fatalf("cid.Decode: %s", err)
+	}
+	if !shardOf(c, 0, 1) {
+		t.Fatalf("shardOf with m=1 should always match")
+	}
+}
+
+func TestParseReadDataSubset(t *testing.T) {
+	n, m, err := parseReadDataSubset("1/4")
+	if err != nil || n != 1 || m != 4 {
+		t.Fatalf("parseReadDataSubset(1/4) = (%d, %d, %v), want (1, 4, nil)", n, m, err)
+	}
+
+	for _, bad := range []string{"", "1", "4/1", "-1/4", "x/4"} {
+		if _, _, err := parseReadDataSubset(bad); err == nil {
+			t.Errorf("parseReadDataSubset(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	cmds "gx/ipfs/QmTjNRVt2fvaRFu93keEC7z5M1GS1iH6qZ9227htQioTUY/go-ipfs-cmds"
+	ds "gx/ipfs/QmXRKBQA4wXP7xWbFiZsR1GP4HV6wMDQ1aWFxZZ4uBcPX9/go-datastore"
+	dsq "gx/ipfs/QmXRKBQA4wXP7xWbFiZsR1GP4HV6wMDQ1aWFxZZ4uBcPX9/go-datastore/query"
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	cmdkit "gx/ipfs/QmceUdzxkimdYsgtX733uNgzf1DLHyBKN6ehGSp85ayppM/go-ipfs-cmdkit"
+)
+
+// pinMetaPrefix namespaces the sidecar datastore entries that "repo
+// forget" uses to remember which group a named pin belongs to and when
+// it was created, since the pinner itself tracks neither.
+const pinMetaPrefix = "/local/pinmeta/"
+
+// PinMeta is the sidecar record written alongside a named, grouped pin so
+// that "repo forget" retention policies survive a daemon restart.
+type PinMeta struct {
+	Group     string
+	CreatedAt time.Time
+	Tags      map[string]string `json:",omitempty"`
+}
+
+func pinMetaKey(c *cid.Cid) ds.Key {
+	return ds.NewKey(pinMetaPrefix + c.String())
+}
+
+// RecordPinGroup persists the group, creation time and optional tags for
+// a pin so a later "repo forget --group=..." run can apply retention
+// policies to it. It is meant to be called by "ipfs pin add --group=...".
+func RecordPinGroup(dstore ds.Datastore, c *cid.Cid, group string, tags map[string]string) error {
+	meta := PinMeta{Group: group, CreatedAt: time.Now(), Tags: tags}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return dstore.Put(pinMetaKey(c), b)
+}
+
+type pinCandidate struct {
+	key  string
+	meta PinMeta
+}
+
+func loadPinGroup(dstore ds.Datastore, group string) ([]pinCandidate, error) {
+	results, err := dstore.Query(dsq.Query{Prefix: pinMetaPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var out []pinCandidate
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var meta PinMeta
+		if err := json.Unmarshal(r.Entry.Value, &meta); err != nil {
+			continue
+		}
+		if meta.Group != group {
+			continue
+		}
+		out = append(out, pinCandidate{key: r.Entry.Key[len(pinMetaPrefix):], meta: meta})
+	}
+	return out, nil
+}
+
+// keepLastN keeps the N most recent candidates unconditionally.
+func keepLastN(sorted []pinCandidate, n int) map[string]bool {
+	kept := make(map[string]bool)
+	for i := 0; i < n && i < len(sorted); i++ {
+		kept[sorted[i].key] = true
+	}
+	return kept
+}
+
+// keepByCalendarBucket walks sorted (newest first) and keeps the newest
+// candidate in each of up to n distinct buckets, using restic's forget
+// bucketing scheme (e.g. one bucket per YYYY-MM-DD for --keep-daily).
+func keepByCalendarBucket(sorted []pinCandidate, n int, bucketFn func(time.Time) string) map[string]bool {
+	kept := make(map[string]bool)
+	if n <= 0 {
+		return kept
+	}
+	seen := make(map[string]bool)
+	for _, c := range sorted {
+		bk := bucketFn(c.meta.CreatedAt)
+		if seen[bk] {
+			continue
+		}
+		if len(seen) >= n {
+			break
+		}
+		seen[bk] = true
+		kept[c.key] = true
+	}
+	return kept
+}
+
+func dailyBucket(t time.Time) string {
+	y, m, d := t.Date()
+	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+}
+
+func weeklyBucket(t time.Time) string {
+	y, w := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", y, w)
+}
+
+func monthlyBucket(t time.Time) string {
+	y, m, _ := t.Date()
+	return fmt.Sprintf("%04d-%02d", y, m)
+}
+
+// ForgetEntry describes one candidate pin's fate under a "repo forget"
+// retention run.
+type ForgetEntry struct {
+	Key     string
+	Group   string
+	Created time.Time
+	Kept    bool
+	Reason  string `json:",omitempty"`
+}
+
+var repoForgetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Apply retention policies to grouped pins.",
+		ShortDescription: `
+'ipfs repo forget' applies restic-style retention rules to pins tagged
+with '--group' at pin time: '--keep-last', '--keep-daily',
+'--keep-weekly' and '--keep-monthly' each keep the newest pin in every
+bucket they define, and the union of all kept pins survives. Everything
+else is unpinned. Combine with '--prune' to reclaim the space
+afterwards.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("group", "Only consider pins tagged with this group."),
+		cmdkit.IntOption("keep-last", "Keep the N most recent pins."),
+		cmdkit.IntOption("keep-daily", "Keep the most recent pin for each of the last N days."),
+		cmdkit.IntOption("keep-weekly", "Keep the most recent pin for each of the last N weeks."),
+		cmdkit.IntOption("keep-monthly", "Keep the most recent pin for each of the last N months."),
+		cmdkit.BoolOption("dry-run", "Show what would be forgotten without unpinning anything."),
+		cmdkit.BoolOption("prune", "Run a garbage collection ('repo gc') after forgetting to reclaim space."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		group, _ := req.Options["group"].(string)
+		if group == "" {
+			res.SetError(fmt.Errorf("--group is required"), cmdkit.ErrClient)
+			return
+		}
+		keepLast, _ := req.Options["keep-last"].(int)
+		keepDaily, _ := req.Options["keep-daily"].(int)
+		keepWeekly, _ := req.Options["keep-weekly"].(int)
+		keepMonthly, _ := req.Options["keep-monthly"].(int)
+		dryRun, _ := req.Options["dry-run"].(bool)
+		prune, _ := req.Options["prune"].(bool)
+
+		dstore := n.Repo.Datastore()
+		raw, err := loadPinGroup(dstore, group)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		var sorted []pinCandidate
+		for _, c := range raw {
+			decoded, err := cid.Decode(c.key)
+			if err != nil {
+				continue
+			}
+			if _, pinned, err := n.Pinning.IsPinned(decoded); err != nil || !pinned {
+				continue
+			}
+			sorted = append(sorted, c)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].meta.CreatedAt.After(sorted[j].meta.CreatedAt)
+		})
+
+		keep := make(map[string]bool)
+		merge := func(m map[string]bool) {
+			for k := range m {
+				keep[k] = true
+			}
+		}
+		merge(keepLastN(sorted, keepLast))
+		merge(keepByCalendarBucket(sorted, keepDaily, dailyBucket))
+		merge(keepByCalendarBucket(sorted, keepWeekly, weeklyBucket))
+		merge(keepByCalendarBucket(sorted, keepMonthly, monthlyBucket))
+
+		for _, c := range sorted {
+			kept := keep[c.key]
+			reason := "forgotten"
+			if kept {
+				reason = "kept"
+			}
+			res.Emit(&ForgetEntry{Key: c.key, Group: group, Created: c.meta.CreatedAt, Kept: kept, Reason: reason})
+
+			if kept || dryRun {
+				continue
+			}
+
+			decoded, err := cid.Decode(c.key)
+			if err != nil {
+				continue
+			}
+			if err := n.Pinning.Unpin(req.Context, decoded, true); err != nil {
+				res.Emit(&ForgetEntry{Key: c.key, Group: group, Created: c.meta.CreatedAt, Kept: false, Reason: fmt.Sprintf("unpin failed: %s", err)})
+				continue
+			}
+			dstore.Delete(pinMetaKey(decoded))
+		}
+
+		if !dryRun {
+			if err := n.Pinning.Flush(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		if prune && !dryRun {
+			gcOut := corerepo.GarbageCollectAsync(n, req.Context)
+			if err := corerepo.CollectResult(req.Context, gcOut, func(k *cid.Cid) {
+				res.Emit(&ForgetEntry{Key: k.String(), Reason: "pruned"})
+			}); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+	},
+	Type: ForgetEntry{},
+}
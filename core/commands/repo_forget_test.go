@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func mustCandidate(key string, created time.Time) pinCandidate {
+	return pinCandidate{key: key, meta: PinMeta{CreatedAt: created}}
+}
+
+func TestKeepLastN(t *testing.T) {
+	now := time.Date(2026, 7, 26, 0, 0, 0, 0, time.UTC)
+	sorted := []pinCandidate{
+		mustCandidate("a", now),
+		mustCandidate("b", now.AddDate(0, 0, -1)),
+		mustCandidate("c", now.AddDate(0, 0, -2)),
+	}
+
+	kept := keepLastN(sorted, 2)
+	if len(kept) != 2 || !kept["a"] || !kept["b"] || kept["c"] {
+		t.Fatalf("keepLastN(2) = %v, want {a, b}", kept)
+	}
+
+	if kept := keepLastN(sorted, 0); len(kept) != 0 {
+		t.Fatalf("keepLastN(0) = %v, want empty", kept)
+	}
+
+	if kept := keepLastN(sorted, 10); len(kept) != 3 {
+		t.Fatalf("keepLastN(10) = %v, want all 3 kept", kept)
+	}
+}
+
+func TestKeepByCalendarBucketDaily(t *testing.T) {
+	day0 := time.Date(2026, 7, 26, 9, 0, 0, 0, time.UTC)
+	sorted := []pinCandidate{
+		mustCandidate("newest-today", day0),
+		mustCandidate("older-today", day0.Add(-time.Hour)),
+		mustCandidate("yesterday", day0.AddDate(0, 0, -1)),
+	}
+
+	kept := keepByCalendarBucket(sorted, 2, dailyBucket)
+	if len(kept) != 2 {
+		t.Fatalf("keepByCalendarBucket(2 days) kept %d, want 2", len(kept))
+	}
+	if !kept["newest-today"] {
+		t.Fatalf("expected newest-today to win its day's bucket, got %v", kept)
+	}
+	if kept["older-today"] {
+		t.Fatalf("older-today should lose to newest-today in the same bucket, got %v", kept)
+	}
+	if !kept["yesterday"] {
+		t.Fatalf("expected yesterday to fill the second bucket, got %v", kept)
+	}
+}
+
+func TestKeepByCalendarBucketZero(t *testing.T) {
+	sorted := []pinCandidate{mustCandidate("a", time.Now())}
+	if kept := keepByCalendarBucket(sorted, 0, dailyBucket); len(kept) != 0 {
+		t.Fatalf("keepByCalendarBucket(0) = %v, want empty", kept)
+	}
+}
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "gx/ipfs/QmceUdzxkimdYsgtX733uNgzf1DLHyBKN6ehGSp85ayppM/go-ipfs-cmdkit"
+)
+
+// backupRepo tars and gzips the repo directory at configRoot into a
+// timestamped archive inside destDir, before any migration step touches
+// the on-disk data.
+func backupRepo(configRoot, destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	archivePath := filepath.Join(destDir, fmt.Sprintf("repo-backup-%d.tar.gz", time.Now().UnixNano()))
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	return filepath.Walk(configRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(configRoot, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		fh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		_, err = io.Copy(tw, fh)
+		return err
+	})
+}
+
+// Migration is implemented by a single fs-repo migration step. Third
+// parties add steps to the migration chain by calling RegisterMigration
+// from an init() function in an imported package.
+type Migration interface {
+	// Apply upgrades the repo at repoPath from Versions().from to
+	// Versions().to.
+	Apply(repoPath string) error
+	// Revert undoes Apply, downgrading the repo back to Versions().from.
+	Revert(repoPath string) error
+	// Versions reports the repo version this step upgrades from and to.
+	Versions() (from, to int)
+}
+
+var migrations []Migration
+
+// RegisterMigration adds a step to the migration chain run by
+// "ipfs repo migrate". It is meant to be called from an init() function.
+func RegisterMigration(m Migration) {
+	migrations = append(migrations, m)
+}
+
+// MigrateStep is emitted once per migration step that "ipfs repo migrate"
+// applies (or plans to apply, under --dry-run).
+type MigrateStep struct {
+	From       int
+	To         int
+	Status     string
+	DurationMs int64
+}
+
+const repoVersionFile = "version"
+
+func readRepoVersion(configRoot string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(configRoot, repoVersionFile))
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid repo version file: %s", err)
+	}
+	return v, nil
+}
+
+// planMigration resolves the ordered chain of registered migrations
+// needed to go from on-disk version `from` to target version `to`. It
+// returns an error if any hop in the chain is missing. For an upgrade
+// (to > from) the chain is walked forward and applied with Apply; for a
+// downgrade (to < from) it's walked backward and the caller is expected
+// to run it with Revert, in the same step order returned here.
+func planMigration(from, to int) ([]Migration, error) {
+	if to >= from {
+		byFrom := make(map[int]Migration, len(migrations))
+		for _, m := range migrations {
+			f, _ := m.Versions()
+			byFrom[f] = m
+		}
+
+		var chain []Migration
+		cur := from
+		for cur != to {
+			m, ok := byFrom[cur]
+			if !ok {
+				return nil, fmt.Errorf("no migration registered starting at version %d", cur)
+			}
+			_, next := m.Versions()
+			chain = append(chain, m)
+			cur = next
+		}
+		return chain, nil
+	}
+
+	byTo := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		_, t := m.Versions()
+		byTo[t] = m
+	}
+
+	var chain []Migration
+	cur := from
+	for cur != to {
+		m, ok := byTo[cur]
+		if !ok {
+			return nil, fmt.Errorf("no migration registered ending at version %d", cur)
+		}
+		prev, _ := m.Versions()
+		chain = append(chain, m)
+		cur = prev
+	}
+	return chain, nil
+}
+
+var repoMigrateCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run repo migrations in-process.",
+		ShortDescription: `
+'ipfs repo migrate' upgrades the on-disk repo at $IPFS_PATH to the
+version this ipfs binary expects, without needing to shell out to the
+external fs-repo-migrations tool. The daemon must not be running.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("to", "Stop early at this repo version instead of the latest."),
+		cmdkit.BoolOption("dry-run", "Print the planned migration chain without applying it."),
+		cmdkit.StringOption("backup", "Directory to tar+gzip the repo into before starting."),
+		cmdkit.BoolOption("allow-downgrade", "Allow migrating to an older repo version."),
+	},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		configRoot := req.InvocContext().ConfigRoot
+
+		onDisk, err := readRepoVersion(configRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		target := fsrepo.RepoVersion
+		if to, found, err := req.Option("to").Int(); err == nil && found {
+			target = to
+		}
+
+		allowDowngrade, _, _ := req.Option("allow-downgrade").Bool()
+		if target < onDisk && !allowDowngrade {
+			res.SetError(fmt.Errorf("refusing to downgrade repo from version %d to %d without --allow-downgrade", onDisk, target), cmdkit.ErrNormal)
+			return
+		}
+
+		chain, err := planMigration(onDisk, target)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		dryRun, _, _ := req.Option("dry-run").Bool()
+		if backupDir, _, _ := req.Option("backup").String(); backupDir != "" && !dryRun {
+			if err := backupRepo(configRoot, backupDir); err != nil {
+				res.SetError(fmt.Errorf("backup failed: %s", err), cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+		defer close(out)
+
+		downgrade := target < onDisk
+		cur := onDisk
+		for _, m := range chain {
+			from, to := m.Versions()
+			status := "ok"
+
+			start := time.Now()
+			if !dryRun {
+				if downgrade {
+					if err := m.Revert(configRoot); err != nil {
+						status = fmt.Sprintf("failed: %s", err)
+					}
+				} else {
+					if err := m.Apply(configRoot); err != nil {
+						status = fmt.Sprintf("failed: %s", err)
+					}
+				}
+			} else {
+				status = "planned"
+			}
+			dur := time.Since(start)
+
+			select {
+			case out <- &MigrateStep{From: from, To: to, Status: status, DurationMs: dur.Nanoseconds() / int64(time.Millisecond)}:
+			case <-req.Context().Done():
+				return
+			}
+
+			if !dryRun && strings.HasPrefix(status, "failed") {
+				res.SetError(fmt.Errorf("migration step %d -> %d failed: %s", from, to, status), cmdkit.ErrNormal)
+				return
+			}
+			cur = to
+			if downgrade {
+				cur = from
+			}
+
+			// Persist after every successful step, not just once at the
+			// end: if a later step in the chain fails, a retried
+			// "repo migrate" must see the steps that already succeeded
+			// and resume from there instead of re-applying them.
+			if !dryRun && status == "ok" {
+				if err := ioutil.WriteFile(filepath.Join(configRoot, repoVersionFile), []byte(strconv.Itoa(cur)), 0644); err != nil {
+					res.SetError(fmt.Errorf("migration step %d -> %d applied but failed to persist repo version: %s", from, to, err), cmdkit.ErrNormal)
+					return
+				}
+			}
+		}
+	},
+	Type: MigrateStep{},
+}
@@ -0,0 +1,84 @@
+package commands
+
+import "testing"
+
+type fakeMigration struct {
+	from, to int
+}
+
+func (f fakeMigration) Apply(repoPath string) error  { return nil }
+func (f fakeMigration) Revert(repoPath string) error { return nil }
+func (f fakeMigration) Versions() (from, to int)     { return f.from, f.to }
+
+func withMigrations(t *testing.T, steps []fakeMigration, fn func()) {
+	t.Helper()
+	saved := migrations
+	migrations = nil
+	for _, s := range steps {
+		migrations = append(migrations, s)
+	}
+	defer func() { migrations = saved }()
+	fn()
+}
+
+func TestPlanMigrationForward(t *testing.T) {
+	withMigrations(t, []fakeMigration{{1, 2}, {2, 3}, {3, 4}}, func() {
+		chain, err := planMigration(1, 4)
+		if err != nil {
+			t.Fatalf("planMigration(1, 4): %s", err)
+		}
+		if len(chain) != 3 {
+			t.Fatalf("chain length = %d, want 3", len(chain))
+		}
+		for i, m := range chain {
+			from, to := m.Versions()
+			if from != i+1 || to != i+2 {
+				t.Errorf("chain[%d] = %d->%d, want %d->%d", i, from, to, i+1, i+2)
+			}
+		}
+	})
+}
+
+func TestPlanMigrationBackward(t *testing.T) {
+	withMigrations(t, []fakeMigration{{1, 2}, {2, 3}, {3, 4}}, func() {
+		chain, err := planMigration(4, 1)
+		if err != nil {
+			t.Fatalf("planMigration(4, 1): %s", err)
+		}
+		if len(chain) != 3 {
+			t.Fatalf("chain length = %d, want 3", len(chain))
+		}
+		// Walked backward, so the first step reverted is 3->4.
+		from, to := chain[0].Versions()
+		if from != 3 || to != 4 {
+			t.Errorf("chain[0] = %d->%d, want 3->4", from, to)
+		}
+		from, to = chain[2].Versions()
+		if from != 1 || to != 2 {
+			t.Errorf("chain[2] = %d->%d, want 1->2", from, to)
+		}
+	})
+}
+
+func TestPlanMigrationNoOpWhenAlreadyAtTarget(t *testing.T) {
+	withMigrations(t, []fakeMigration{{1, 2}}, func() {
+		chain, err := planMigration(2, 2)
+		if err != nil {
+			t.Fatalf("planMigration(2, 2): %s", err)
+		}
+		if len(chain) != 0 {
+			t.Errorf("chain length = %d, want 0", len(chain))
+		}
+	})
+}
+
+func TestPlanMigrationMissingHop(t *testing.T) {
+	withMigrations(t, []fakeMigration{{1, 2}}, func() {
+		if _, err := planMigration(1, 5); err == nil {
+			t.Error("planMigration(1, 5) with no migration registered at version 2 should error")
+		}
+		if _, err := planMigration(5, 1); err == nil {
+			t.Error("planMigration(5, 1) with no migration registered ending at version 5 should error")
+		}
+	})
+}
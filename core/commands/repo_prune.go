@@ -0,0 +1,311 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	dshelp "gx/ipfs/QmSz8kAe2JCKp2dWSG8gHSWnwSmne8aEppmo22rKauNw8L/go-ipfs-ds-help"
+	cmds "gx/ipfs/QmTjNRVt2fvaRFu93keEC7z5M1GS1iH6qZ9227htQioTUY/go-ipfs-cmds"
+	ds "gx/ipfs/QmXRKBQA4wXP7xWbFiZsR1GP4HV6wMDQ1aWFxZZ4uBcPX9/go-datastore"
+	bstore "gx/ipfs/QmaG4DZ4JaqEfvPWt5nPPgoTzhc1tr1T3f4Nu9Jpdm8ymY/go-ipfs-blockstore"
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	cmdkit "gx/ipfs/QmceUdzxkimdYsgtX733uNgzf1DLHyBKN6ehGSp85ayppM/go-ipfs-cmdkit"
+)
+
+// PruneResult is streamed once per removed or kept block, and once more at
+// the end of the run carrying the aggregate summary.
+type PruneResult struct {
+	Key     *cid.Cid `json:",omitempty"`
+	Removed bool     `json:",omitempty"`
+	Error   string   `json:",omitempty"`
+
+	BlocksKept     int    `json:",omitempty"`
+	BlocksRemoved  int    `json:",omitempty"`
+	BytesReclaimed uint64 `json:",omitempty"`
+	Duration       string `json:",omitempty"`
+}
+
+// repoBloom is a minimal fixed-size Bloom filter over CIDs, sized up front
+// from an expected element count and target false-positive rate. It is only
+// ever used to decide whether a block is *possibly* reachable, so a false
+// positive just means a reachable-looking block survives an extra GC cycle;
+// it never causes a reachable block to be deleted.
+type repoBloom struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newRepoBloom(expectedN int, fp float64) *repoBloom {
+	if expectedN < 1 {
+		expectedN = 1
+	}
+	m := uint64(math.Ceil(-1 * float64(expectedN) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(expectedN) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &repoBloom{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+func (b *repoBloom) positions(c *cid.Cid) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write(c.Bytes())
+	sum1 := h1.Sum64()
+
+	h2 := fnv.New64()
+	h2.Write(c.Bytes())
+	sum2 := h2.Sum64()
+
+	pos := make([]uint64, b.k)
+	for i := uint64(0); i < b.k; i++ {
+		pos[i] = (sum1 + i*sum2) % b.m
+	}
+	return pos
+}
+
+func (b *repoBloom) Add(c *cid.Cid) {
+	for _, p := range b.positions(c) {
+		b.bits[p/64] |= 1 << (p % 64)
+	}
+}
+
+func (b *repoBloom) Has(c *cid.Cid) bool {
+	for _, p := range b.positions(c) {
+		if b.bits[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mtimeDatastore is implemented by flatfs, the datastore backing a normal
+// fs-repo's blockstore, which can report when a key was last written.
+// repoPruneCmd consults it, when available, to honour --keep-recent;
+// datastores that don't implement it (e.g. an in-memory store in tests)
+// are always eligible.
+type mtimeDatastore interface {
+	Modified(k ds.Key) (time.Time, error)
+}
+
+// colorReachable runs the same pin-set colouring pass used by "repo gc": it
+// walks every recursive, direct and internal pin's DAG and returns the full
+// set of CIDs that must survive a sweep.
+func colorReachable(ctx context.Context, n *core.IpfsNode) (*cid.Set, error) {
+	reachable := cid.NewSet()
+	roots := n.Pinning.RecursiveKeys()
+	roots = append(roots, n.Pinning.DirectKeys()...)
+	roots = append(roots, n.Pinning.InternalPins()...)
+
+	seen := cid.NewSet()
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		reachable.Add(c)
+		nd, err := n.DAG.Get(ctx, c)
+		if err != nil {
+			// Best-effort: an unreadable child shouldn't abort the whole
+			// colouring pass, it just can't be marked reachable further.
+			return nil
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, r := range roots {
+		if err := walk(r); err != nil {
+			return nil, err
+		}
+	}
+	return reachable, nil
+}
+
+var repoPruneCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Prune unpinned blocks from the repo.",
+		ShortDescription: `
+'ipfs repo prune' goes beyond 'ipfs repo gc': in addition to an
+all-or-nothing sweep it supports pruning to a size quota, pruning with
+bounded parallelism, and keeping recently-written blocks around even if
+they are unpinned.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("dry-run", "Compute and print what would be removed without touching the datastore."),
+		cmdkit.IntOption("max-size", "Prune the oldest-written unpinned blocks first until RepoSize falls under this many bytes."),
+		cmdkit.IntOption("parallel", "Number of parallel workers that classify blocks as kept or removable (default 1); the pin walk and the deletions run serially."),
+		cmdkit.StringOption("keep-recent", "Never GC blocks written more recently than this duration (e.g. \"24h\"); has no effect when the datastore cannot report write times."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		dryRun, _ := req.Options["dry-run"].(bool)
+		maxSize, haveMaxSize := req.Options["max-size"].(int)
+		parallel, _ := req.Options["parallel"].(int)
+		if parallel <= 0 {
+			parallel = 1
+		}
+
+		var keepRecent time.Duration
+		if s, ok := req.Options["keep-recent"].(string); ok && s != "" {
+			keepRecent, err = time.ParseDuration(s)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid --keep-recent duration: %s", err), cmdkit.ErrClient)
+				return
+			}
+		}
+
+		start := time.Now()
+
+		stat, err := corerepo.RepoStat(n, req.Context)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		reachable, err := colorReachable(req.Context, n)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		filter := newRepoBloom(stat.NumObjects, 0.01)
+		reachable.ForEach(func(c *cid.Cid) error {
+			filter.Add(c)
+			return nil
+		})
+
+		bs := bstore.NewBlockstore(n.Repo.Datastore())
+		mtimeDS, _ := n.Repo.Datastore().(mtimeDatastore)
+
+		keys, err := bs.AllKeysChan(req.Context)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		// Mark phase: classify every key as kept (reachable or within
+		// --keep-recent) or a sweep candidate. Candidates are buffered
+		// rather than deleted as they're found, so that --max-size can
+		// later sort them oldest-first and so the quota check (which
+		// needs an exact running total) doesn't have to race workers
+		// over a bounded channel.
+		type candidate struct {
+			key   *cid.Cid
+			size  int
+			mtime time.Time
+		}
+
+		var kept int
+		var candMu sync.Mutex
+		var candidates []candidate
+
+		jobs := make(chan *cid.Cid, parallel*4)
+
+		var wg sync.WaitGroup
+		wg.Add(parallel)
+		for i := 0; i < parallel; i++ {
+			go func() {
+				defer wg.Done()
+				for k := range jobs {
+					if filter.Has(k) {
+						candMu.Lock()
+						kept++
+						candMu.Unlock()
+						res.Emit(&PruneResult{Key: k, Removed: false})
+						continue
+					}
+
+					var mt time.Time
+					if mtimeDS != nil {
+						mt, _ = mtimeDS.Modified(ds.NewKey("/blocks").Child(dshelp.CidToDsKey(k)))
+					}
+					if keepRecent > 0 && !mt.IsZero() && time.Since(mt) < keepRecent {
+						candMu.Lock()
+						kept++
+						candMu.Unlock()
+						res.Emit(&PruneResult{Key: k, Removed: false})
+						continue
+					}
+
+					size, _ := bs.GetSize(k)
+					candMu.Lock()
+					candidates = append(candidates, candidate{key: k, size: size, mtime: mt})
+					candMu.Unlock()
+				}
+			}()
+		}
+
+		for k := range keys {
+			select {
+			case jobs <- k:
+			case <-req.Context.Done():
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		// Sweep phase: for --max-size, prune the oldest-written candidates
+		// first (by datastore mtime; candidates without one sort first, in
+		// no defined order), stopping as soon as the repo would fall under
+		// the target size; otherwise (plain "repo gc"-style sweep) order
+		// doesn't matter and every candidate is removed.
+		if haveMaxSize {
+			sort.Slice(candidates, func(i, j int) bool {
+				return candidates[i].mtime.Before(candidates[j].mtime)
+			})
+		}
+
+		var removed int
+		var reclaimed uint64
+		for _, c := range candidates {
+			if haveMaxSize && int64(stat.RepoSize)-int64(reclaimed) <= int64(maxSize) {
+				kept++
+				res.Emit(&PruneResult{Key: c.key, Removed: false})
+				continue
+			}
+
+			if !dryRun {
+				if err := bs.DeleteBlock(c.key); err != nil {
+					res.Emit(&PruneResult{Key: c.key, Error: err.Error()})
+					continue
+				}
+			}
+
+			removed++
+			if c.size > 0 {
+				reclaimed += uint64(c.size)
+			}
+			res.Emit(&PruneResult{Key: c.key, Removed: true})
+		}
+
+		res.Emit(&PruneResult{
+			BlocksKept:     kept,
+			BlocksRemoved:  removed,
+			BytesReclaimed: reclaimed,
+			Duration:       time.Since(start).String(),
+		})
+	},
+	Type: PruneResult{},
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"math"
+	"testing"
+
+	cid "gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+)
+
+func mustCid(t *testing.T, s string) *cid.Cid {
+	t.Helper()
+	c, err := cid.Decode(s)
+	if err != nil {
+		t.Fatalf("cid.Decode(%q): %s", s, err)
+	}
+	return c
+}
+
+func TestNewRepoBloomSizing(t *testing.T) {
+	const n = 1000
+	const fp = 0.01
+
+	filter := newRepoBloom(n, fp)
+
+	wantM := uint64(math.Ceil(-1 * float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	if filter.m != wantM {
+		t.Errorf("m = %d, want %d", filter.m, wantM)
+	}
+	if got, want := uint64(len(filter.bits)), (wantM+63)/64; got != want {
+		t.Errorf("len(bits) = %d words, want %d", got, want)
+	}
+	if filter.k < 1 {
+		t.Errorf("k = %d, want at least 1", filter.k)
+	}
+}
+
+func TestNewRepoBloomClampsDegenerateInputs(t *testing.T) {
+	filter := newRepoBloom(0, 0.01)
+	if filter.m < 64 {
+		t.Errorf("m = %d for expectedN<1, want the 64-bit floor to be applied", filter.m)
+	}
+	if filter.k < 1 {
+		t.Errorf("k = %d, want at least 1", filter.k)
+	}
+}
+
+func TestRepoBloomNoFalseNegatives(t *testing.T) {
+	added := []string{
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
+		"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB",
+	}
+
+	filter := newRepoBloom(len(added), 0.01)
+	for _, s := range added {
+		filter.Add(mustCid(t, s))
+	}
+
+	for _, s := range added {
+		if !filter.Has(mustCid(t, s)) {
+			t.Errorf("bloom filter reports a false negative for added cid %s", s)
+		}
+	}
+}
+
+func TestRepoBloomEmptyFilterHasNothing(t *testing.T) {
+	filter := newRepoBloom(10, 0.01)
+	if filter.Has(mustCid(t, "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n")) {
+		t.Errorf("empty bloom filter should not report any cid as present")
+	}
+}